@@ -114,10 +114,7 @@ class TestStage4 : StageTest<String>() {
             checkOutputString(TestedProgram().start("add"), "Tracked files:\n$fileName1\n$fileName2")
 
             val notExistsFileName = "file${Random.nextInt(0, 1000)}.txt"
-            checkOutputString(
-                TestedProgram().start("add", notExistsFileName),
-                "Can't find '$notExistsFileName'."
-            )
+            checkFailure(arrayOf("add", notExistsFileName), 4, "Can't find '$notExistsFileName'.")
         } finally {
             deleteVcsDir()
             deleteFiles(file1, file2)
@@ -185,7 +182,7 @@ class TestStage4 : StageTest<String>() {
             TestedProgram().start("add", file2.name)
 
             checkOutputString(TestedProgram().start("log"), "No commits yet.")
-            checkOutputString(TestedProgram().start("commit"), "Message was not passed.")
+            checkFailure(arrayOf("commit"), 2, "Message was not passed.")
             checkOutputString(TestedProgram().start("commit", "Test message"), "Changes are committed.")
 
             var got = TestedProgram().start("log")
@@ -200,7 +197,7 @@ class TestStage4 : StageTest<String>() {
             )
             checkLogOutput(got, want, regex)
 
-            checkOutputString(TestedProgram().start("commit", "Test message2"), "Nothing to commit.")
+            checkFailure(arrayOf("commit", "Test message2"), 5, "Nothing to commit.")
 
             file2.appendText("some text")
             checkOutputString(TestedProgram().start("commit", "Test message3"), "Changes are committed.")
@@ -226,21 +223,22 @@ class TestStage4 : StageTest<String>() {
             val commitHashes = parseCommitHashes(got)
             commitHashes.forEach { commitHash ->
 
-                val commitDirPath = "vcs${separatorChar}commits$separatorChar$commitHash"
-                val commitDir = File(commitDirPath)
-                val versionedFile1 = commitDir.resolve(file1.name)
-                val versionedFile2 = commitDir.resolve(file2.name)
-                val feedbackMessage = "\n\nMake sure you make versions of tracked files on a folder named with the commitId"
+                val commitFilePath = "vcs${separatorChar}commits$separatorChar$commitHash"
+                val commitFile = File(commitFilePath)
+                val feedbackMessage = "\n\nMake sure you record every commit in a file named with the commitId " +
+                        "and store versions of tracked files in the object store"
 
-                when{
-                    commitDir.exists().not() || commitDir.isDirectory.not() ->
-                        return CheckResult.wrong("Could not find folder $commitDirPath$feedbackMessage")
-
-                    versionedFile1.exists().not() ->
-                        return CheckResult.wrong("Could not find file ${versionedFile1.name} on $commitDirPath$feedbackMessage")
+                if (commitFile.exists().not() || commitFile.isFile.not()) {
+                    return CheckResult.wrong("Could not find file $commitFilePath$feedbackMessage")
+                }
 
-                    versionedFile2.exists().not() ->
-                        return CheckResult.wrong("Could not find file ${versionedFile2.name} on $commitDirPath$feedbackMessage")
+                val tree = TestedProgram().start("ls-tree", commitHash)
+                listOf(file1, file2).forEach { file ->
+                    if (tree.lines().none { it.endsWith("\t${file.name}") }) {
+                        return CheckResult.wrong(
+                            "Could not find file ${file.name} in the files of commit $commitHash$feedbackMessage"
+                        )
+                    }
                 }
             }
 
@@ -287,8 +285,8 @@ class TestStage4 : StageTest<String>() {
 
             TestedProgram().start("commit", "Second commit")
 
-            checkOutputString(TestedProgram().start("checkout"), "Commit id was not passed.")
-            checkOutputString(TestedProgram().start("checkout", "wrongId"), "Commit does not exist.")
+            checkFailure(arrayOf("checkout"), 2, "Commit id was not passed.")
+            checkFailure(arrayOf("checkout", "wrongId"), 4, "Commit does not exist.")
 
             val parsedHashes = parseCommitHashes(TestedProgram().start("log"))
             if (parsedHashes.isEmpty()){
@@ -355,7 +353,7 @@ class TestStage4 : StageTest<String>() {
     fun wrongArgTest(): CheckResult {
         try {
             val suffix = Random.nextInt(0,1000)
-            checkOutputString(TestedProgram().start("wrongArg$suffix"), "'wrongArg$suffix' is not a SVCS command.")
+            checkFailure(arrayOf("wrongArg$suffix"), 2, "'wrongArg$suffix' is not a SVCS command.")
         } finally {
             deleteVcsDir()
         }
@@ -435,6 +433,30 @@ class TestStage4 : StageTest<String>() {
         }
     }
 
+    // Failures go to standard error with a nonzero exit code, which TestedProgram reports as a crash,
+    // so those commands run as a plain process.
+    private fun checkFailure(args: Array<String>, code: Int, want: String) {
+        val process = ProcessBuilder("go", "run", "main.go", *args).start()
+        process.outputStream.close()
+        val stdout = process.inputStream.bufferedReader().readText()
+        val stderr = process.errorStream.bufferedReader().readText()
+        val exitCode = process.waitFor()
+
+        if (stdout.isNotBlank()) {
+            throw WrongAnswer(
+                "Your program should print \"$want\" to standard error,\n" +
+                        "but printed to standard output: \"$stdout\""
+            )
+        }
+        if (exitCode != code) {
+            throw WrongAnswer(
+                "Your program should exit with code $code after \"$want\",\n" +
+                        "but exited with code $exitCode"
+            )
+        }
+        checkOutputString(stderr, want)
+    }
+
     private fun getRandomUserName() =
         listOf("Marie", "Anna", "Diane", "Sofie", "Christine").random() + Random.nextInt(1000)
 
@@ -0,0 +1,160 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"strings"
+	"syscall/js"
+)
+
+/*
+This file is only part of the WebAssembly build:
+
+	GOOS=js GOARCH=wasm go build -o vcs.wasm main.go wasm_js.go
+
+Instead of running the command line interface, the module registers a global `vcs` object whose
+functions wrap the pure parts of the core (object decoding, trees, commits, the log and diffs).
+None of them touch a filesystem: the host fetches the raw files of a repository (for instance
+vcs/objects/<xx>/<rest> over HTTP) and passes their bytes in, so a browser can browse history and
+render diffs client-side.
+*/
+
+func init() {
+	runEmbedded = serveJS
+}
+
+func serveJS() {
+	// There is no vcs/format to read, so the functions assume the default format until the host
+	// names the hash of its repository with setHash
+	format, _ := parseRepositoryFormat(nil)
+	loadedFormat = &format
+
+	js.Global().Set("vcs", js.ValueOf(map[string]any{
+		"setHash":      js.FuncOf(jsSetHash),
+		"hashObject":   js.FuncOf(jsHashObject),
+		"decodeObject": js.FuncOf(jsDecodeObject),
+		"parseTree":    js.FuncOf(jsParseTree),
+		"parseCommit":  js.FuncOf(jsParseCommit),
+		"parseLog":     js.FuncOf(jsParseLog),
+		"diff":         js.FuncOf(jsDiff),
+	}))
+
+	// Keep the module alive so the callbacks stay usable
+	select {}
+}
+
+// jsSetHash(hash: string) sets the hash algorithm of the repository, the "hash" line of its
+// vcs/format file: "sha256" (the default) or "sha512".
+func jsSetHash(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return jsError("setHash(hash) takes 1 argument")
+	}
+	format, err := parseRepositoryFormat([]byte("hash " + args[0].String() + "\n"))
+	if err != nil {
+		return jsError(err.Error())
+	}
+	loadedFormat = &format
+	return nil
+}
+
+// jsHashObject(kind: string, content: Uint8Array) returns the hash the object is stored under.
+func jsHashObject(this js.Value, args []js.Value) any {
+	if len(args) != 2 {
+		return jsError("hashObject(kind, content) takes 2 arguments")
+	}
+	return hashObject(args[0].String(), jsBytes(args[1]))
+}
+
+// jsDecodeObject(stored: Uint8Array) returns {kind, content} for a file of vcs/objects.
+func jsDecodeObject(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return jsError("decodeObject(stored) takes 1 argument")
+	}
+	kind, content, err := decodeStoredObject(jsBytes(args[0]))
+	if err != nil {
+		return jsError(err.Error())
+	}
+	return map[string]any{"kind": kind, "content": jsUint8Array(content)}
+}
+
+// jsParseTree(content: Uint8Array) returns the entries of a tree object.
+func jsParseTree(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return jsError("parseTree(content) takes 1 argument")
+	}
+	entries, err := parseTree(jsBytes(args[0]))
+	if err != nil {
+		return jsError(err.Error())
+	}
+	list := make([]any, len(entries))
+	for i, entry := range entries {
+		list[i] = map[string]any{"mode": entry.Mode, "kind": entry.Kind, "hash": entry.Hash, "name": entry.Name, "mtime": entry.Mtime}
+	}
+	return list
+}
+
+// jsParseCommit(content: Uint8Array) returns {tree, parents} for a file of vcs/commits.
+func jsParseCommit(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return jsError("parseCommit(content) takes 1 argument")
+	}
+	record, err := parseCommitRecord(jsBytes(args[0]))
+	if err != nil {
+		return jsError(err.Error())
+	}
+	return map[string]any{"tree": record.Tree, "parents": jsStrings(record.Parents)}
+}
+
+// jsParseLog(content: Uint8Array) returns the commits listed by log.txt, newest first.
+func jsParseLog(this js.Value, args []js.Value) any {
+	if len(args) != 1 {
+		return jsError("parseLog(content) takes 1 argument")
+	}
+	commits, err := parseLog(jsBytes(args[0]))
+	if err != nil {
+		return jsError(err.Error())
+	}
+	list := make([]any, len(commits))
+	for i, commit := range commits {
+		list[i] = map[string]any{"hash": commit.HashID, "author": commit.Author, "message": commit.Message}
+	}
+	return list
+}
+
+// jsDiff(before: string, after: string) returns the line operations turning before into after,
+// as {kind: " " | "-" | "+", line} objects.
+func jsDiff(this js.Value, args []js.Value) any {
+	if len(args) != 2 {
+		return jsError("diff(before, after) takes 2 arguments")
+	}
+	ops := diffLines(splitLines([]byte(args[0].String())), splitLines([]byte(args[1].String())))
+	list := make([]any, len(ops))
+	for i, op := range ops {
+		list[i] = map[string]any{"kind": string(op.Kind), "line": op.Line}
+	}
+	return list
+}
+
+func jsError(message string) any {
+	return map[string]any{"error": strings.TrimSpace(message)}
+}
+
+func jsBytes(value js.Value) []byte {
+	data := make([]byte, value.Get("length").Int())
+	js.CopyBytesToGo(data, value)
+	return data
+}
+
+func jsUint8Array(data []byte) js.Value {
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	return array
+}
+
+func jsStrings(values []string) []any {
+	list := make([]any, len(values))
+	for i, value := range values {
+		list[i] = value
+	}
+	return list
+}